@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Normalization modes for the -N flag, combined as a bitmask so e.g. "-N=case -N=path" only
+// normalizes those two aspects of a URL.
+const (
+	normCase uint8 = 1 << iota
+	normPort
+	normPath
+	normQuery
+
+	normAll = normCase | normPort | normPath | normQuery
+)
+
+// defaultPorts maps schemes to the port RFC 3986 §6.2.3 treats as equivalent to no port at all.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// normalizeFlag implements flag.Value for -N, accumulating the normalization modes requested on
+// the command line. It also implements the unexported boolFlag interface so a bare "-N" (with no
+// "=MODE") is accepted and means "-N=all".
+type normalizeFlag struct {
+	mask  uint8
+	isSet bool
+}
+
+func (n *normalizeFlag) Set(v string) error {
+	switch v {
+	case "", "true", "all":
+		n.mask |= normAll
+	case "case":
+		n.mask |= normCase
+	case "port":
+		n.mask |= normPort
+	case "path":
+		n.mask |= normPath
+	case "query":
+		n.mask |= normQuery
+	default:
+		return fmt.Errorf("unknown -N mode %q (want case, port, path, query, or all)", v)
+	}
+	n.isSet = true
+	return nil
+}
+
+func (n *normalizeFlag) String() string {
+	if n == nil || n.mask == 0 {
+		return ""
+	}
+	var modes []string
+	if n.mask&normCase != 0 {
+		modes = append(modes, "case")
+	}
+	if n.mask&normPort != 0 {
+		modes = append(modes, "port")
+	}
+	if n.mask&normPath != 0 {
+		modes = append(modes, "path")
+	}
+	if n.mask&normQuery != 0 {
+		modes = append(modes, "query")
+	}
+	return strings.Join(modes, ",")
+}
+
+func (n *normalizeFlag) IsBoolFlag() bool { return true }
+
+// normalizeURL applies the RFC 3986 §6.2.2/§6.2.3 syntax-based normalizations selected by mask to
+// u, in place. Repeated application is idempotent and never changes the semantic identity of u.
+func normalizeURL(u *url.URL, mask uint8) {
+	if mask&normCase != 0 {
+		u.Scheme = strings.ToLower(u.Scheme)
+		if h, p, err := net.SplitHostPort(u.Host); err == nil {
+			u.Host = net.JoinHostPort(strings.ToLower(h), p)
+		} else {
+			u.Host = strings.ToLower(u.Host)
+		}
+		normalizePercentEscapes(u)
+	}
+
+	if mask&normPort != 0 {
+		if h, p, err := net.SplitHostPort(u.Host); err == nil {
+			if def, ok := defaultPorts[strings.ToLower(u.Scheme)]; ok && p == def {
+				u.Host = h
+			}
+		}
+	}
+
+	if mask&normPath != 0 {
+		u.Path = removeDotSegments(u.Path)
+		if u.Path == "" && u.Host != "" {
+			u.Path = "/"
+		}
+	}
+
+	if mask&normQuery != 0 && u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+}
+
+// normalizePercentEscapes rewrites the percent-escaped components of u per RFC 3986 §6.2.2.1:
+// %XX sequences that encode an unreserved character are decoded to that character, and the hex
+// digits of any remaining escapes are uppercased.
+func normalizePercentEscapes(u *url.URL) {
+	if p := unescapeUnreserved(u.EscapedPath()); p != "" {
+		// Decode directly with url.PathUnescape rather than url.Parse: a path that starts
+		// with "//" (e.g. from "http://host//evil.com/x") would otherwise be misread as a
+		// scheme-relative authority, silently dropping everything after the first "//".
+		if decoded, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = decoded, p
+		}
+	}
+	u.RawQuery = unescapeUnreserved(u.RawQuery)
+	if f := unescapeUnreserved(u.EscapedFragment()); f != "" {
+		if decoded, err := url.PathUnescape(f); err == nil {
+			u.Fragment, u.RawFragment = decoded, f
+		}
+	}
+}
+
+const unreserved = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+
+// unescapeUnreserved decodes %XX escapes of RFC 3986 unreserved characters in s back to those
+// characters, and uppercases the hex digits of any %XX escapes it leaves alone.
+func unescapeUnreserved(s string) string {
+	if !strings.ContainsRune(s, '%') {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			b.WriteByte(s[i])
+			continue
+		}
+		if c := byte(n); strings.IndexByte(unreserved, c) != -1 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(upperHex(s[i+1]))
+			b.WriteByte(upperHex(s[i+2]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func upperHex(b byte) byte {
+	if b >= 'a' && b <= 'f' {
+		return b - 'a' + 'A'
+	}
+	return b
+}
+
+// removeDotSegments implements the RFC 3986 §5.2.4 remove_dot_segments algorithm used to collapse
+// "." and ".." segments out of a URL path.
+func removeDotSegments(p string) string {
+	in := p
+	var out []string
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = "/" + in[3:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = "/" + in[4:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			i := 0
+			if strings.HasPrefix(in, "/") {
+				i = 1
+			}
+			if j := strings.IndexByte(in[i:], '/'); j == -1 {
+				out = append(out, in)
+				in = ""
+			} else {
+				out = append(out, in[:i+j])
+				in = in[i+j:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
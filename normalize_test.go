@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		mask uint8
+		want string
+	}{
+		{"case lowercases scheme and host", "HTTP://Example.COM/Path", normCase, "http://example.com/Path"},
+		{"case decodes unreserved escapes", "http://host/%7Eabc%2D%5F%7E", normCase, "http://host/~abc-_~"},
+		{"case uppercases remaining escapes", "http://host/%2f%3a", normCase, "http://host/%2F%3A"},
+		{"case preserves a path starting with //", "http://host//evil.com/x", normCase, "http://host//evil.com/x"},
+		{"port strips the scheme's default port", "http://host:80/", normPort, "http://host/"},
+		{"port keeps a non-default port", "http://host:8080/", normPort, "http://host:8080/"},
+		{"path removes dot segments", "http://host/a/./b/../c", normPath, "http://host/a/c"},
+		{"path fills in a non-empty path for an authority", "http://host", normPath, "http://host/"},
+		{"query sorts by key but keeps repeat order stable", "http://host/?b=1&a=2&a=1", normQuery, "http://host/?a=2&a=1&b=1"},
+		{"all composes every mode", "HTTP://Host:80/a/./b?b=2&a=1", normAll, "http://host/a/b?a=1&b=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.in)
+			if err != nil {
+				t.Fatalf("url.Parse(%q): %v", tt.in, err)
+			}
+			normalizeURL(u, tt.mask)
+			if got := u.String(); got != tt.want {
+				t.Errorf("normalizeURL(%q, %#x) = %q, want %q", tt.in, tt.mask, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	// Examples from RFC 3986 §5.2.4.
+	tests := []struct{ in, want string }{
+		{"/a/b/c/./../../g", "/a/g"},
+		{"mid/content=5/../6", "mid/6"},
+		{"", ""},
+		{"/a/b/c", "/a/b/c"},
+	}
+	for _, tt := range tests {
+		if got := removeDotSegments(tt.in); got != tt.want {
+			t.Errorf("removeDotSegments(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type queryOpKind int
+
+const (
+	queryAppendOp queryOpKind = iota
+	querySetOp
+	queryDeleteOp
+	querySortOp
+	queryMergeOp
+)
+
+type queryOp struct {
+	kind      queryOpKind
+	key       string
+	val       string
+	mergeURL  *url.URL
+	mergeMode string
+}
+
+// queryOps is an ordered list of query-string edits built up from the -q, -qs, -qd, -qsort, and
+// -qm flags, in the order they're given on the command line, so that e.g. "-qsort" only affects
+// values appended before it and "-qm" merges according to the -qm-mode in effect at the time.
+type queryOps []queryOp
+
+// apply runs every op in ops against u's query string, in order.
+func (ops queryOps) apply(u *url.URL) {
+	if len(ops) == 0 {
+		return
+	}
+
+	q := u.Query()
+	for _, op := range ops {
+		switch op.kind {
+		case queryAppendOp:
+			q[op.key] = append(q[op.key], op.val)
+		case querySetOp:
+			q[op.key] = []string{op.val}
+		case queryDeleteOp:
+			delete(q, op.key)
+		case querySortOp:
+			for k := range q {
+				sort.Strings(q[k])
+			}
+		case queryMergeOp:
+			for k, v := range op.mergeURL.Query() {
+				switch op.mergeMode {
+				case "replace":
+					q[k] = append([]string(nil), v...)
+				case "keep":
+					if _, ok := q[k]; !ok {
+						q[k] = append([]string(nil), v...)
+					}
+				default: // "append"
+					q[k] = append(q[k], v...)
+				}
+			}
+		}
+	}
+
+	// Always re-encode, even when q is now empty: deleting the last remaining key must clear
+	// RawQuery rather than leaving the stale pre-edit query string in place.
+	u.RawQuery = q.Encode()
+}
+
+func splitKV(s string) (k, v string) {
+	eq := strings.IndexByte(s, '=')
+	if eq == -1 {
+		return s, ""
+	}
+	return s[:eq], s[eq+1:]
+}
+
+// queryAppendFlag implements flag.Value for -q, appending a ?K=V query value.
+type queryAppendFlag struct{ ops *queryOps }
+
+func (f queryAppendFlag) Set(s string) error {
+	k, v := splitKV(s)
+	*f.ops = append(*f.ops, queryOp{kind: queryAppendOp, key: k, val: v})
+	return nil
+}
+
+func (f queryAppendFlag) String() string { return "?K=V" }
+
+// querySetFlag implements flag.Value for -qs, replacing all values of a query key.
+type querySetFlag struct{ ops *queryOps }
+
+func (f querySetFlag) Set(s string) error {
+	k, v := splitKV(s)
+	*f.ops = append(*f.ops, queryOp{kind: querySetOp, key: k, val: v})
+	return nil
+}
+
+func (f querySetFlag) String() string { return "?K=V" }
+
+// queryDeleteFlag implements flag.Value for -qd, deleting a query key.
+type queryDeleteFlag struct{ ops *queryOps }
+
+func (f queryDeleteFlag) Set(s string) error {
+	*f.ops = append(*f.ops, queryOp{kind: queryDeleteOp, key: s})
+	return nil
+}
+
+func (f queryDeleteFlag) String() string { return "K" }
+
+// querySortFlag implements flag.Value (and the boolFlag interface) for -qsort.
+type querySortFlag struct{ ops *queryOps }
+
+func (f querySortFlag) Set(string) error {
+	*f.ops = append(*f.ops, queryOp{kind: querySortOp})
+	return nil
+}
+
+func (f querySortFlag) String() string { return "" }
+
+func (f querySortFlag) IsBoolFlag() bool { return true }
+
+// queryMergeFlag implements flag.Value for -qm, merging another URL's query string into this
+// one's, using the collision mode most recently set via -qm-mode.
+type queryMergeFlag struct {
+	ops  *queryOps
+	mode *string
+}
+
+func (f queryMergeFlag) Set(s string) error {
+	mu, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("unable to parse merge URL %q: %v", s, err)
+	}
+	*f.ops = append(*f.ops, queryOp{kind: queryMergeOp, mergeURL: mu, mergeMode: *f.mode})
+	return nil
+}
+
+func (f queryMergeFlag) String() string { return "URL" }
+
+// queryMergeModeFlag implements flag.Value for -qm-mode, setting the collision mode applied by
+// -qm flags that follow it.
+type queryMergeModeFlag struct{ mode *string }
+
+func (f queryMergeModeFlag) Set(s string) error {
+	switch s {
+	case "append", "replace", "keep":
+		*f.mode = s
+	default:
+		return fmt.Errorf("unknown -qm-mode %q (want append, replace, or keep)", s)
+	}
+	return nil
+}
+
+func (f queryMergeModeFlag) String() string { return *f.mode }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestQueryOpsApply(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		ops  queryOps
+		want string
+	}{
+		{
+			name: "no ops leaves the query string untouched",
+			in:   "http://h/?a=1",
+			ops:  nil,
+			want: "http://h/?a=1",
+		},
+		{
+			name: "append adds to existing values",
+			in:   "http://h/?a=1",
+			ops:  queryOps{{kind: queryAppendOp, key: "a", val: "2"}},
+			want: "http://h/?a=1&a=2",
+		},
+		{
+			name: "set replaces all existing values",
+			in:   "http://h/?a=1&a=2",
+			ops:  queryOps{{kind: querySetOp, key: "a", val: "3"}},
+			want: "http://h/?a=3",
+		},
+		{
+			name: "delete removes the key",
+			in:   "http://h/?a=1&b=2",
+			ops:  queryOps{{kind: queryDeleteOp, key: "a"}},
+			want: "http://h/?b=2",
+		},
+		{
+			name: "deleting the only key clears the query string",
+			in:   "http://h/?a=1",
+			ops:  queryOps{{kind: queryDeleteOp, key: "a"}},
+			want: "http://h/",
+		},
+		{
+			name: "sort orders repeated values per key",
+			in:   "http://h/?a=2&a=1",
+			ops:  queryOps{{kind: querySortOp}},
+			want: "http://h/?a=1&a=2",
+		},
+		{
+			name: "merge in append mode adds to existing values",
+			in:   "http://h/?a=1",
+			ops:  queryOps{{kind: queryMergeOp, mergeURL: mustParseURL(t, "http://x/?a=2"), mergeMode: "append"}},
+			want: "http://h/?a=1&a=2",
+		},
+		{
+			name: "merge in replace mode overwrites existing values",
+			in:   "http://h/?a=1",
+			ops:  queryOps{{kind: queryMergeOp, mergeURL: mustParseURL(t, "http://x/?a=2"), mergeMode: "replace"}},
+			want: "http://h/?a=2",
+		},
+		{
+			name: "merge in keep mode ignores colliding keys",
+			in:   "http://h/?a=1",
+			ops:  queryOps{{kind: queryMergeOp, mergeURL: mustParseURL(t, "http://x/?a=2&b=3"), mergeMode: "keep"}},
+			want: "http://h/?a=1&b=3",
+		},
+		{
+			name: "ops run in order",
+			in:   "http://h/",
+			ops: queryOps{
+				{kind: queryAppendOp, key: "a", val: "1"},
+				{kind: querySetOp, key: "a", val: "2"},
+				{kind: queryAppendOp, key: "a", val: "3"},
+			},
+			want: "http://h/?a=2&a=3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := mustParseURL(t, tt.in)
+			tt.ops.apply(u)
+			if got := u.String(); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", s, err)
+	}
+	return u
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// isSIPScheme reports whether scheme is one that carries SIP-style ";key=value" path parameters
+// by convention.
+func isSIPScheme(scheme string) bool {
+	switch scheme {
+	case "sip", "sips", "tel":
+		return true
+	}
+	return false
+}
+
+// parseSIPOpaque turns a non-hierarchical sip:/sips:/tel: URI's Opaque (e.g.
+// "alice@atlanta.com;transport=tcp", from "sip:alice@atlanta.com;transport=tcp") into real
+// userinfo and host components. net/url has nowhere else to put them, since the URI has no "//"
+// authority marker to make it hierarchical; the host is left with any trailing ";params" intact,
+// for sipParamTarget to find.
+func parseSIPOpaque(u *url.URL) {
+	rest := u.Opaque
+	if at := strings.IndexByte(rest, '@'); at != -1 {
+		userinfo, host := rest[:at], rest[at+1:]
+		if colon := strings.IndexByte(userinfo, ':'); colon != -1 {
+			u.User = url.UserPassword(sipParamUnescape(userinfo[:colon]), sipParamUnescape(userinfo[colon+1:]))
+		} else {
+			u.User = url.User(sipParamUnescape(userinfo))
+		}
+		rest = host
+	}
+	u.Host = rest
+	u.Opaque = ""
+}
+
+// param is a single SIP-style ";key=value" (or bare ";key") path parameter.
+type param struct {
+	Key   string
+	Value string
+}
+
+// paramList is an ordered list of SIP-style path parameters, preserving the order they were
+// parsed in (or appended in, for new ones).
+type paramList []param
+
+// set adds key=val, replacing any existing parameter with the same key in place.
+func (p paramList) set(key, val string) paramList {
+	for i := range p {
+		if p[i].Key == key {
+			p[i].Value = val
+			return p
+		}
+	}
+	return append(p, param{Key: key, Value: val})
+}
+
+// delete removes any parameter with the given key.
+func (p paramList) delete(key string) paramList {
+	out := p[:0]
+	for _, prm := range p {
+		if prm.Key != key {
+			out = append(out, prm)
+		}
+	}
+	return out
+}
+
+// encode renders the parameter list back to its ";key=value;key2=value2" form.
+func (p paramList) encode() string {
+	var b strings.Builder
+	for _, prm := range p {
+		b.WriteByte(';')
+		b.WriteString(sipParamEscape(prm.Key))
+		if prm.Value != "" {
+			b.WriteByte('=')
+			b.WriteString(sipParamEscape(prm.Value))
+		}
+	}
+	return b.String()
+}
+
+// splitParams splits s into its base (everything up to the parameters) and its ordered parameter
+// list, taking the parameters from the last '/'-delimited segment only, so that a ';' in an
+// earlier segment (e.g. the "old" in "/a;old=1/b;p=1") isn't mistaken for the start of the
+// parameter list and doesn't swallow the segments after it.
+func splitParams(s string) (base string, params paramList) {
+	seg := s
+	if slash := strings.LastIndexByte(s, '/'); slash != -1 {
+		seg = s[slash+1:]
+	}
+
+	i := strings.IndexByte(seg, ';')
+	if i == -1 {
+		return s, nil
+	}
+	i += len(s) - len(seg)
+
+	for _, part := range strings.Split(s[i+1:], ";") {
+		if part == "" {
+			continue
+		}
+		k, v := part, ""
+		if eq := strings.IndexByte(part, '='); eq != -1 {
+			k, v = part[:eq], part[eq+1:]
+		}
+		params = append(params, param{Key: sipParamUnescape(k), Value: sipParamUnescape(v)})
+	}
+	return s[:i], params
+}
+
+// sipParamUnreserved is RFC 3261's "param-unreserved" set plus the generic URI unreserved and
+// mark characters, i.e. the characters that don't need percent-escaping in a SIP parameter.
+const sipParamUnreserved = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789" +
+	"-_.~!*'()" + "[]/:&+$"
+
+func sipParamEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; strings.IndexByte(sipParamUnreserved, c) != -1 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sipParamUnescape(s string) string {
+	if !strings.ContainsRune(s, '%') {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// sipParamTarget returns the accessor for wherever a URL's SIP-style parameters live: the path,
+// if it has one, or the host otherwise (as in "sip:alice@atlanta.com;transport=tcp", which has
+// no path at all).
+func sipParamTarget(u *url.URL) (get func() string, set func(string)) {
+	if u.Path != "" {
+		return func() string { return u.Path }, func(s string) { u.Path = s }
+	}
+	return func() string { return u.Host }, func(s string) { u.Host = s }
+}
+
+// applySIPParams rewrites u's SIP-style path parameters: stripping them all if strip is true,
+// then applying ops (from -pa/-pd) in order, then re-encoding them back onto u. With strip false
+// and no ops, this round-trips the existing parameters unchanged, preserving their order.
+func applySIPParams(u *url.URL, strip bool, ops paramOps) {
+	get, set := sipParamTarget(u)
+	base, params := splitParams(get())
+
+	if strip {
+		params = nil
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case paramSetOp:
+			params = params.set(op.key, op.val)
+		case paramDeleteOp:
+			params = params.delete(op.key)
+		}
+	}
+
+	set(base + params.encode())
+}
+
+type paramOpKind int
+
+const (
+	paramSetOp paramOpKind = iota
+	paramDeleteOp
+)
+
+type paramOp struct {
+	kind paramOpKind
+	key  string
+	val  string
+}
+
+// paramOps is an ordered list of -pa/-pd edits, applied in the order given on the command line.
+type paramOps []paramOp
+
+// paramAddFlag implements flag.Value for -pa, adding or replacing a SIP-style path parameter.
+type paramAddFlag struct{ ops *paramOps }
+
+func (f paramAddFlag) Set(s string) error {
+	k, v := splitKV(s)
+	*f.ops = append(*f.ops, paramOp{kind: paramSetOp, key: k, val: v})
+	return nil
+}
+
+func (f paramAddFlag) String() string { return "k=v" }
+
+// paramDeleteFlag implements flag.Value for -pd, deleting a SIP-style path parameter.
+type paramDeleteFlag struct{ ops *paramOps }
+
+func (f paramDeleteFlag) Set(s string) error {
+	*f.ops = append(*f.ops, paramOp{kind: paramDeleteOp, key: s})
+	return nil
+}
+
+func (f paramDeleteFlag) String() string { return "k" }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSplitParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		wantBase   string
+		wantParams paramList
+	}{
+		{"no params", "atlanta.com", "atlanta.com", nil},
+		{
+			name:       "one param",
+			in:         "atlanta.com;transport=tcp",
+			wantBase:   "atlanta.com",
+			wantParams: paramList{{Key: "transport", Value: "tcp"}},
+		},
+		{
+			name:     "multiple params preserve order",
+			in:       "atlanta.com;transport=tcp;user=phone",
+			wantBase: "atlanta.com",
+			wantParams: paramList{
+				{Key: "transport", Value: "tcp"},
+				{Key: "user", Value: "phone"},
+			},
+		},
+		{
+			name:       "bare param with no value",
+			in:         "atlanta.com;lr",
+			wantBase:   "atlanta.com",
+			wantParams: paramList{{Key: "lr", Value: ""}},
+		},
+		{
+			name:       "percent-escaped key and value are decoded",
+			in:         "atlanta.com;a%3Db=c%3Bd",
+			wantBase:   "atlanta.com",
+			wantParams: paramList{{Key: "a=b", Value: "c;d"}},
+		},
+		{
+			name:       "params only come from the last path segment",
+			in:         "/a;old=1/b;p=1",
+			wantBase:   "/a;old=1/b",
+			wantParams: paramList{{Key: "p", Value: "1"}},
+		},
+		{
+			name:     "a non-last segment with no params of its own is left alone",
+			in:       "/a;old=1/b",
+			wantBase: "/a;old=1/b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, params := splitParams(tt.in)
+			if base != tt.wantBase {
+				t.Errorf("splitParams(%q) base = %q, want %q", tt.in, base, tt.wantBase)
+			}
+			if len(params) != len(tt.wantParams) {
+				t.Fatalf("splitParams(%q) params = %#v, want %#v", tt.in, params, tt.wantParams)
+			}
+			for i := range params {
+				if params[i] != tt.wantParams[i] {
+					t.Errorf("splitParams(%q) params[%d] = %#v, want %#v", tt.in, i, params[i], tt.wantParams[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParamListSetDeleteEncode(t *testing.T) {
+	var p paramList
+	p = p.set("transport", "tcp")
+	p = p.set("user", "phone")
+	if got, want := p.encode(), ";transport=tcp;user=phone"; got != want {
+		t.Fatalf("encode() = %q, want %q", got, want)
+	}
+
+	// set on an existing key replaces it in place, not appending a duplicate.
+	p = p.set("transport", "udp")
+	if got, want := p.encode(), ";transport=udp;user=phone"; got != want {
+		t.Fatalf("encode() after replace = %q, want %q", got, want)
+	}
+
+	p = p.delete("transport")
+	if got, want := p.encode(), ";user=phone"; got != want {
+		t.Fatalf("encode() after delete = %q, want %q", got, want)
+	}
+}
+
+func TestParseSIPOpaque(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantUser string
+		wantHost string
+	}{
+		{
+			name:     "userinfo and host with trailing params",
+			in:       "alice@atlanta.com;transport=tcp",
+			wantUser: "alice",
+			wantHost: "atlanta.com;transport=tcp",
+		},
+		{
+			name:     "no userinfo",
+			in:       "atlanta.com;transport=tcp",
+			wantUser: "",
+			wantHost: "atlanta.com;transport=tcp",
+		},
+		{
+			name:     "userinfo with password",
+			in:       "alice:secret@atlanta.com",
+			wantUser: "alice:secret",
+			wantHost: "atlanta.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &url.URL{Scheme: "sip", Opaque: tt.in}
+			parseSIPOpaque(u)
+
+			if u.Opaque != "" {
+				t.Errorf("Opaque = %q, want empty", u.Opaque)
+			}
+			if u.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", u.Host, tt.wantHost)
+			}
+			gotUser := ""
+			if u.User != nil {
+				gotUser = u.User.String()
+			}
+			if gotUser != tt.wantUser {
+				t.Errorf("User = %q, want %q", gotUser, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestApplySIPParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		u     *url.URL
+		strip bool
+		ops   paramOps
+		want  string
+	}{
+		{
+			name: "round-trips unchanged params with no edits",
+			u:    &url.URL{Scheme: "sip", Host: "atlanta.com;transport=tcp"},
+			want: "atlanta.com;transport=tcp",
+		},
+		{
+			name: "adds a new param",
+			u:    &url.URL{Scheme: "sip", Host: "atlanta.com"},
+			ops:  paramOps{{kind: paramSetOp, key: "transport", val: "tcp"}},
+			want: "atlanta.com;transport=tcp",
+		},
+		{
+			name: "deletes a param",
+			u:    &url.URL{Scheme: "sip", Host: "atlanta.com;transport=tcp;user=phone"},
+			ops:  paramOps{{kind: paramDeleteOp, key: "transport"}},
+			want: "atlanta.com;user=phone",
+		},
+		{
+			name:  "strip removes all existing params before applying ops",
+			u:     &url.URL{Scheme: "sip", Host: "atlanta.com;transport=tcp"},
+			strip: true,
+			ops:   paramOps{{kind: paramSetOp, key: "user", val: "phone"}},
+			want:  "atlanta.com;user=phone",
+		},
+		{
+			name: "targets the path when one is present",
+			u:    &url.URL{Scheme: "sip", Host: "atlanta.com", Path: "/alice;transport=tcp"},
+			ops:  paramOps{{kind: paramDeleteOp, key: "transport"}},
+			want: "/alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applySIPParams(tt.u, tt.strip, tt.ops)
+			get, _ := sipParamTarget(tt.u)
+			if got := get(); got != tt.want {
+				t.Errorf("applySIPParams() target = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
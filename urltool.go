@@ -4,9 +4,11 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
@@ -52,11 +54,83 @@ Modifiers:
   -q K=V
     Append a ?K=V value to the query string. May be repeated. If no '='
     is found, an empty ?K= is added.
+  -qs K=V
+    Set a ?K=V value, replacing any values K already has. May be
+    repeated.
+  -qd K
+    Delete K from the query string. May be repeated.
+  -qsort
+    Sort the query string by key, and sort the values of repeated keys.
+  -qm-mode=append|replace|keep
+    Set the collision mode used by -qm that follows (default "append").
+  -qm URL
+    Merge URL's query string into the current one, following -qm-mode.
+  -q/-qs/-qd/-qsort/-qm are applied in the order given on the command
+  line.
   -f
     Set the URL's #fragment.
   -r
     Parse a URL relative to the input URL and use the result (after all
     other modifiers).
+
+  -ds SCHEME, -dsc SCHEME
+    Set the URL's scheme, but only if it doesn't already have one.
+  -du USER
+    Set the URL's username, but only if it doesn't already have one.
+  -dpw PASSWD
+    Set the URL's password, but only if it doesn't already have one.
+  -dH HOST
+    Set the URL's host, but only if it doesn't already have one.
+  -dP PORT
+    Set the URL's host port, but only if the host doesn't already have one.
+  -dp PATH
+    Set the URL's path, but only if it doesn't already have one.
+
+  -i -|FILE
+    Read URLs to process from the given file, or stdin if given "-",
+    instead of (or in addition to) the positional URLs given on the
+    command line. One URL per line. Used automatically, as if "-i -"
+    were given, when no positional URLs are present.
+  -0
+    Use NUL bytes rather than newlines to delimit URLs read via -i and
+    URLs written to stdout.
+
+  -N[=case|port|path|query|all]
+    Apply RFC 3986 syntax-based normalization, after all other modifiers.
+    May be repeated to enable more than one of the sub-normalizations;
+    bare -N (or -N=all) enables all of them. Repeated application is
+    idempotent.
+      case   Lowercase the scheme and host, and normalize percent-escapes
+             (decode unreserved characters, uppercase remaining hex).
+      port   Remove the port if it's the scheme's default (http:80,
+             https:443, ws:80, wss:443, ftp:21).
+      path   Remove . and .. path segments, and ensure a non-empty path
+             for URLs with an authority.
+      query  Sort the query string by key, preserving the order of
+             repeated values.
+
+  -F FORMAT
+    Change how each URL is printed, instead of the default u.String().
+    FORMAT is one of the presets "url" (the default), "json" (one JSON
+    object per URL, i.e. NDJSON), "host", "hostport", "authority",
+    "origin", "path+query", or a text/template string evaluated against
+    a struct with the fields Scheme, User, Pass, Host, Hostname, Port,
+    Path, RawPath, Query (map[string][]string), RawQuery, Fragment,
+    Opaque, and IsAbs.
+
+  -sip
+    Treat the URL's SIP-style ";key=value" path parameters (carried on
+    the last path segment, or on the host if there's no path, as in
+    "sip:alice@atlanta.com;transport=tcp") as first-class components
+    instead of leaving them as part of the path/host. Enabled
+    automatically when the URL's scheme is sip, sips, or tel.
+  -pa k=v
+    Add or replace the SIP-style path parameter k. May be repeated.
+  -pd k
+    Delete the SIP-style path parameter k. May be repeated.
+  -ps[=true|false]
+    Strip all of the URL's SIP-style path parameters.
+  -pa/-pd are applied in the order given on the command line, after -ps.
 `)
 	os.Exit(2)
 }
@@ -82,50 +156,93 @@ func main() {
 	}
 
 	newline := ""
+	delim := "\n"
+	var format *formatter
 	for len(argv) > 0 {
-		urls, rest, err := parseArgs(argv)
+		urls, rest, nulDelim, formatStr, err := parseArgs(argv)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			code = 1
 		}
+		if nulDelim {
+			delim = "\x00"
+		}
+		if formatStr != "" {
+			fm, err := newFormatter(formatStr)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			} else {
+				format = fm
+			}
+		}
 
 		for _, u := range urls {
+			s := u.String()
+			if format != nil {
+				fs, err := format.format(u)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					code = 1
+					continue
+				}
+				s = fs
+			}
 			_, _ = out.WriteString(newline)
-			newline = "\n"
-			_, _ = out.WriteString(u.String())
+			newline = delim
+			_, _ = out.WriteString(s)
 		}
 
 		argv = rest
 	}
 }
 
-func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
+func parseArgs(args []string) (urls []*url.URL, rest []string, nulDelim bool, format string, err error) {
 	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
 		us := args[0]
 		u, err := url.Parse(us)
 		if err != nil {
-			return nil, nil, fmt.Errorf("unable to parse URL %q: %v", us, err)
+			return nil, nil, false, "", fmt.Errorf("unable to parse URL %q: %v", us, err)
 		}
 		urls = append(urls, u)
 		args = args[1:]
 	}
 
 	var (
-		nohacks       bool
-		scheme        SetString
-		opaque        SetString
-		username      SetString
-		password      SetString
-		stripUser     bool
-		host          SetString
-		port          SetString
-		newPath       SetString
-		joinPath      bool
-		forceQuery    bool
-		stripQuery    bool
-		query         queryArgs
-		fragment      SetString
-		parseRelative SetString
+		nohacks        bool
+		scheme         SetString
+		opaque         SetString
+		username       SetString
+		password       SetString
+		stripUser      bool
+		host           SetString
+		port           SetString
+		newPath        SetString
+		joinPath       bool
+		forceQuery     bool
+		stripQuery     bool
+		query          queryOps
+		queryMergeMode = "append"
+		fragment       SetString
+		parseRelative  SetString
+
+		defaultScheme   SetString
+		defaultUsername SetString
+		defaultPassword SetString
+		defaultHost     SetString
+		defaultPort     SetString
+		defaultPath     SetString
+
+		input SetString
+		nul   bool
+
+		normalizeMode normalizeFlag
+
+		formatOpt SetString
+
+		sipMode     bool
+		stripParams bool
+		params      paramOps
 	)
 
 	f := flag.NewFlagSet("urltool", flag.ExitOnError)
@@ -142,23 +259,64 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 	f.BoolVar(&joinPath, "j", false, "force joining the URL's path instead of setting it when relative")
 	f.BoolVar(&forceQuery, "fq", false, "force a ? to appear in the URL")
 	f.BoolVar(&stripQuery, "sq", false, "strip query string before appending to it")
-	f.Var(&query, "q", "append a ?K=V value to the query string")
+	f.Var(queryAppendFlag{&query}, "q", "append a ?K=V value to the query string")
+	f.Var(querySetFlag{&query}, "qs", "set (replace all values of) ?K=V in the query string")
+	f.Var(queryDeleteFlag{&query}, "qd", "delete ?K from the query string; may be repeated")
+	f.Var(querySortFlag{&query}, "qsort", "sort the query string by key, and sort the values of repeated keys")
+	f.Var(queryMergeModeFlag{&queryMergeMode}, "qm-mode", "set the collision mode (append, replace, or keep) for -qm that follows")
+	f.Var(queryMergeFlag{&query, &queryMergeMode}, "qm", "merge another `URL`'s query string into this one")
 	f.Var(&fragment, "f", "set the URL's #fragment")
 	f.Var(&parseRelative, "r", "parse a `URL` relative to the input URL and use the result")
+	f.Var(&defaultScheme, "ds", "set the URL's scheme, but only if it doesn't already have one")
+	f.Var(&defaultScheme, "dsc", "alias of -ds")
+	f.Var(&defaultUsername, "du", "set the URL's username, but only if it doesn't already have one")
+	f.Var(&defaultPassword, "dpw", "set the URL's password, but only if it doesn't already have one")
+	f.Var(&defaultHost, "dH", "set the URL's host, but only if it doesn't already have one")
+	f.Var(&defaultPort, "dP", "set the URL's host port, but only if the host doesn't already have one")
+	f.Var(&defaultPath, "dp", "set the URL's path, but only if it doesn't already have one")
+	f.Var(&input, "i", "read URLs from `FILE` (or - for stdin) instead of, or in addition to, the command line")
+	f.BoolVar(&nul, "0", false, "use NUL bytes rather than newlines to delimit URLs read via -i and written to stdout")
+	f.Var(&normalizeMode, "N", "apply RFC 3986 normalization (case, port, path, query, or all); repeatable")
+	f.Var(&formatOpt, "F", "print URLs using a preset or `text/template` format instead of u.String()")
+	f.BoolVar(&sipMode, "sip", false, "treat ;key=value path parameters as first-class components (implied for sip/sips/tel)")
+	f.Var(paramAddFlag{&params}, "pa", "add or replace the SIP-style path parameter k=v")
+	f.Var(paramDeleteFlag{&params}, "pd", "delete the SIP-style path parameter k")
+	f.BoolVar(&stripParams, "ps", false, "strip all of the URL's SIP-style path parameters")
 	if err := f.Parse(args); err != nil {
-		return nil, nil, err
+		return nil, nil, false, "", err
+	}
+
+	// If no positional URLs were given, fall back to reading them from stdin, as if "-i -" had
+	// been given.
+	if len(urls) == 0 && !input.IsSet {
+		input.IsSet, input.Str = true, "-"
+	}
+
+	if input.IsSet {
+		read, err := readURLs(input.Str, nul)
+		if err != nil {
+			return nil, nil, nul, "", fmt.Errorf("unable to read URLs from %q: %v", input.Str, err)
+		}
+		urls = append(urls, read...)
 	}
 
 	// Wait until here to check how many URLs there are, since the user might be passing -h or
 	// -help.
 	if len(urls) == 0 {
-		return nil, nil, errors.New("no URLs given")
+		return nil, nil, nul, "", errors.New("no URLs given")
 	}
 
 	for i, u := range urls {
+		sipActive := sipMode || isSIPScheme(u.Scheme)
+
 		if nohacks {
 			// Skip the following URL hacks
-		} else if at := strings.IndexByte(u.Opaque, '@'); u.Scheme != "" && at != -1 && u.Host == "" && u.Path == "" && u.User == nil {
+		} else if sipActive && u.Opaque != "" && u.Host == "" && u.Path == "" && u.User == nil {
+			// Account for non-hierarchical sip:/sips:/tel: URIs, e.g.
+			// "sip:alice@atlanta.com;transport=tcp": net/url has nowhere to put the
+			// userinfo and host but Opaque, since there's no "//" authority marker.
+			parseSIPOpaque(u)
+		} else if at := strings.IndexByte(u.Opaque, '@'); !sipActive && u.Scheme != "" && at != -1 && u.Host == "" && u.Path == "" && u.User == nil {
 			// Try to account for user:pass@domain style URLs
 			user := u.Scheme
 			pass := u.Opaque[:at]
@@ -180,6 +338,8 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 		// Scheme
 		if scheme.IsSet {
 			u.Scheme = scheme.Str
+		} else if defaultScheme.IsSet && u.Scheme == "" {
+			u.Scheme = defaultScheme.Str
 		}
 
 		// Opaque
@@ -195,9 +355,13 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 		pass, _ := u.User.Password() // nil-safe
 		if username.IsSet {
 			user = username.Str
+		} else if defaultUsername.IsSet && user == "" {
+			user = defaultUsername.Str
 		}
 		if password.IsSet {
 			pass = password.Str
+		} else if defaultPassword.IsSet && pass == "" {
+			pass = defaultPassword.Str
 		}
 		if user != "" || pass != "" {
 			u.User = url.UserPassword(user, pass)
@@ -206,18 +370,27 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 		// Hostname
 		if host.IsSet {
 			u.Host = host.Str
+		} else if defaultHost.IsSet && u.Host == "" {
+			u.Host = defaultHost.Str
 		}
 
 		// Host port
 		if port.IsSet {
 			if _, err := strconv.ParseUint(port.Str, 10, 64); err != nil {
-				return nil, nil, fmt.Errorf("invalid port number %q", port.Str)
+				return nil, nil, nul, "", fmt.Errorf("invalid port number %q", port.Str)
 			}
 			h, _, err := net.SplitHostPort(u.Host)
 			if err != nil {
 				h = u.Host
 			}
 			u.Host = net.JoinHostPort(h, port.Str)
+		} else if defaultPort.IsSet && u.Host != "" {
+			if _, err := strconv.ParseUint(defaultPort.Str, 10, 64); err != nil {
+				return nil, nil, nul, "", fmt.Errorf("invalid port number %q", defaultPort.Str)
+			}
+			if _, _, err := net.SplitHostPort(u.Host); err != nil {
+				u.Host = net.JoinHostPort(u.Host, defaultPort.Str)
+			}
 		}
 
 		// Path
@@ -235,6 +408,8 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 			if strings.HasPrefix(u.Path, "/../") {
 				u.Path = "/"
 			}
+		} else if defaultPath.IsSet && u.Path == "" {
+			u.Path = defaultPath.Str
 		}
 
 		// Query string
@@ -242,13 +417,7 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 		if stripQuery {
 			u.RawQuery = ""
 		}
-		q := u.Query()
-		for k, v := range query {
-			q[k] = append(q[k], v...)
-		}
-		if len(q) != 0 {
-			u.RawQuery = q.Encode()
-		}
+		query.apply(u)
 
 		// Fragment
 		if fragment.IsSet {
@@ -259,36 +428,25 @@ func parseArgs(args []string) (urls []*url.URL, rest []string, err error) {
 		if parseRelative.IsSet {
 			r, err := u.Parse(parseRelative.Str)
 			if err != nil {
-				return nil, nil, fmt.Errorf("unable to parse %q relative to %q: %v", r, u, err)
+				return nil, nil, nul, "", fmt.Errorf("unable to parse %q relative to %q: %v", r, u, err)
 			}
 			u = r
 		}
 
-		urls[i] = u
-	}
-
-	return urls, f.Args(), nil
-}
+		// SIP-style path parameters
+		if sipMode || isSIPScheme(u.Scheme) {
+			applySIPParams(u, stripParams, params)
+		}
 
-type queryArgs url.Values
+		// RFC 3986 normalization
+		if normalizeMode.isSet {
+			normalizeURL(u, normalizeMode.mask)
+		}
 
-func (q *queryArgs) Set(s string) error {
-	if *q == nil {
-		*q = queryArgs{}
-	}
-	m := *q
-	eq := strings.IndexByte(s, '=')
-	if eq == -1 {
-		m[s] = append(m[s], "")
-		return nil
+		urls[i] = u
 	}
-	k, v := s[:eq], s[eq+1:]
-	m[k] = append(m[k], v)
-	return nil
-}
 
-func (q queryArgs) String() string {
-	return "?K=V"
+	return urls, f.Args(), nul, formatOpt.Str, nil
 }
 
 type SetString struct {
@@ -305,6 +463,58 @@ func (s SetString) String() string {
 	return s.Str
 }
 
+// readURLs reads newline- (or, if nul is true, NUL-) delimited URLs from src, which is either a
+// path to a file or "-" for os.Stdin.
+func readURLs(src string, nul bool) ([]*url.URL, error) {
+	r := io.Reader(os.Stdin)
+	if src != "-" {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	sc := bufio.NewScanner(r)
+	if nul {
+		sc.Split(scanNULTerminated)
+	}
+
+	var urls []*url.URL
+	for sc.Scan() {
+		us := sc.Text()
+		if us == "" {
+			continue
+		}
+		u, err := url.Parse(us)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse URL %q: %v", us, err)
+		}
+		urls = append(urls, u)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// scanNULTerminated is a bufio.SplitFunc like bufio.ScanLines, but splits on NUL bytes instead of
+// newlines.
+func scanNULTerminated(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 // isTTY attempts to determine whether the current stdout refers to a terminal.
 func isTTY() bool {
 	fi, err := os.Stdout.Stat()
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestParseArgsDefaultOnlyFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"ds sets the scheme when unset", []string{"http://h/", "-ds", "https"}, "http://h/"},
+		{"ds is a no-op when the scheme is already set", []string{"https://h/", "-ds", "http"}, "https://h/"},
+		{"dsc is an alias of ds", []string{"http://h/", "-dsc", "https"}, "http://h/"},
+		{"du sets the username when unset", []string{"http://h/", "-du", "alice"}, "http://alice:@h/"},
+		{"du is a no-op when the user is already set", []string{"http://bob@h/", "-du", "alice"}, "http://bob:@h/"},
+		{"dH sets the host when unset", []string{"/foo", "-dH", "h"}, "//h/foo"},
+		{"dp sets the path when unset", []string{"http://h", "-dp", "/x"}, "http://h/x"},
+		{"dp is a no-op when the path is already set", []string{"http://h/y", "-dp", "/x"}, "http://h/y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, _, _, _, err := parseArgs(tt.args)
+			if err != nil {
+				t.Fatalf("parseArgs(%v): %v", tt.args, err)
+			}
+			if len(urls) != 1 {
+				t.Fatalf("parseArgs(%v) returned %d URLs, want 1", tt.args, len(urls))
+			}
+			if got := urls[0].String(); got != tt.want {
+				t.Errorf("parseArgs(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsDefaultPort(t *testing.T) {
+	urls, _, _, _, err := parseArgs([]string{"http://h/", "-dP", "8080"})
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if got, want := urls[0].String(), "http://h:8080/"; got != want {
+		t.Errorf("parseArgs = %q, want %q", got, want)
+	}
+
+	urls, _, _, _, err = parseArgs([]string{"http://h:80/", "-dP", "8080"})
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if got, want := urls[0].String(), "http://h:80/"; got != want {
+		t.Errorf("parseArgs with an existing port = %q, want %q", got, want)
+	}
+}
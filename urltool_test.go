@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadURLs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(path, []byte("http://a/\nhttp://b/\n\nhttp://c/\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	urls, err := readURLs(path, false)
+	if err != nil {
+		t.Fatalf("readURLs: %v", err)
+	}
+
+	want := []string{"http://a/", "http://b/", "http://c/"}
+	if len(urls) != len(want) {
+		t.Fatalf("readURLs returned %d URLs, want %d", len(urls), len(want))
+	}
+	for i, u := range urls {
+		if got := u.String(); got != want[i] {
+			t.Errorf("urls[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestReadURLsNULDelimited(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(path, []byte("http://a/\x00http://b/\x00"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	urls, err := readURLs(path, true)
+	if err != nil {
+		t.Fatalf("readURLs: %v", err)
+	}
+
+	want := []string{"http://a/", "http://b/"}
+	if len(urls) != len(want) {
+		t.Fatalf("readURLs returned %d URLs, want %d", len(urls), len(want))
+	}
+	for i, u := range urls {
+		if got := u.String(); got != want[i] {
+			t.Errorf("urls[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestReadURLsInvalidURL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(path, []byte("http://a b/\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := readURLs(path, false); err == nil {
+		t.Errorf("readURLs with an invalid URL should have errored")
+	}
+}
+
+func TestScanNULTerminated(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		atEOF       bool
+		wantAdvance int
+		wantToken   string
+	}{
+		{"splits on NUL", "abc\x00def", false, 4, "abc"},
+		{"no NUL yet, not at EOF, requests more data", "abc", false, 0, ""},
+		{"no NUL at EOF returns the rest", "abc", true, 3, "abc"},
+		{"empty at EOF returns nothing", "", true, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance, token, err := scanNULTerminated([]byte(tt.data), tt.atEOF)
+			if err != nil {
+				t.Fatalf("scanNULTerminated: %v", err)
+			}
+			if advance != tt.wantAdvance {
+				t.Errorf("advance = %d, want %d", advance, tt.wantAdvance)
+			}
+			if string(token) != tt.wantToken {
+				t.Errorf("token = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+}